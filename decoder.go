@@ -0,0 +1,580 @@
+package nbt2json
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+)
+
+// EventType identifies what a Decoder.Token call returned.
+type EventType int
+
+const (
+	// EventTagStart opens a TAG_List or TAG_Compound; a matching
+	// EventTagEnd follows once its contents have been consumed.
+	EventTagStart EventType = iota
+	// EventTagEnd closes the TAG_List or TAG_Compound most recently
+	// opened by an EventTagStart.
+	EventTagEnd
+	// EventPrimitive is a complete scalar tag (everything but
+	// TAG_List, TAG_Compound, TAG_Byte_Array and TAG_Int_Array).
+	EventPrimitive
+	// EventArrayChunk is a bounded-size slice of a TAG_Byte_Array or
+	// TAG_Int_Array, so large arrays need not be held in memory at once.
+	EventArrayChunk
+)
+
+// Event is one step of token-by-token NBT decoding, as returned by
+// Decoder.Token.
+type Event struct {
+	Type EventType
+	// TagType is the NBT tag type this event belongs to.
+	TagType byte
+	// Name is the tag's name. It is only meaningful when Bare is
+	// false: list items are unnamed. Every chunk of a named array
+	// carries the array's name.
+	Name string
+	// Bare is true for values nested directly inside a TAG_List, which
+	// carry no tagType/name of their own in the equivalent JSON.
+	Bare bool
+	// ListType is the element tag type of a TAG_List; only set on the
+	// EventTagStart that opens one.
+	ListType byte
+	// Value holds the decoded scalar for EventPrimitive, or the
+	// []byte/[]int32 chunk for EventArrayChunk.
+	Value interface{}
+	// First is true on the first EventArrayChunk of a given array.
+	First bool
+	// Remaining is how many array elements are left after this chunk;
+	// zero means this was the last chunk.
+	Remaining int64
+}
+
+// arrayChunkSize bounds how many array elements Decoder reads at a time.
+const arrayChunkSize = 4096
+
+const (
+	frameList = iota
+	frameCompound
+	frameArray
+)
+
+type decoderFrame struct {
+	kind         int
+	itemType     byte // frameList: type of its items. frameArray: 7 or 11.
+	remaining    int64
+	name         string // frameArray only: name to attach to each of its chunks
+	bare         bool   // frameArray only: whether the array is a TAG_List item
+	emittedFirst bool   // frameArray only
+}
+
+// Decoder pulls NBT data one tag/item/array-chunk at a time via Token,
+// instead of building the whole tag tree in memory the way Nbt2Json does.
+// This lets callers transform or filter enormous files with bounded
+// memory use.
+type Decoder struct {
+	r         *bytes.Reader
+	byteOrder binary.ByteOrder
+	format    NbtFormat
+	mode      JsonOutputMode
+	stack     []*decoderFrame
+	started   bool
+	done      bool
+}
+
+// NewDecoder returns a Decoder reading NBT data from r. mode carries
+// through to EncodeEventsToJSON, which renders Token's raw events the
+// same way Nbt2Json would for the given JsonOutputMode.
+func NewDecoder(r *bytes.Reader, byteOrder binary.ByteOrder, format NbtFormat, mode JsonOutputMode) *Decoder {
+	return &Decoder{r: r, byteOrder: byteOrder, format: format, mode: mode}
+}
+
+// Token returns the next decoding event, or io.EOF once the root tag (and
+// everything it contains) has been fully consumed.
+func (d *Decoder) Token() (Event, error) {
+	if d.done {
+		return Event{}, io.EOF
+	}
+	if len(d.stack) == 0 {
+		if d.started {
+			d.done = true
+			return Event{}, io.EOF
+		}
+		d.started = true
+		ev, err := d.readNamedTag()
+		if err == nil && ev.Type == EventTagEnd {
+			// A root TAG_End closes nothing, so it is reported as a
+			// payload-less primitive instead.
+			ev = Event{Type: EventPrimitive, TagType: 0}
+		}
+		return ev, err
+	}
+	top := d.stack[len(d.stack)-1]
+	switch top.kind {
+	case frameList:
+		if top.remaining == 0 {
+			d.stack = d.stack[:len(d.stack)-1]
+			return Event{Type: EventTagEnd, TagType: 9, Bare: true}, nil
+		}
+		top.remaining--
+		return d.readListItem(top.itemType)
+	case frameCompound:
+		ev, err := d.readNamedTag()
+		if err != nil {
+			return Event{}, err
+		}
+		if ev.Type == EventTagEnd {
+			d.stack = d.stack[:len(d.stack)-1]
+			ev.TagType = 10
+		}
+		return ev, nil
+	case frameArray:
+		return d.readArrayChunk(top)
+	}
+	return Event{}, NbtParseError{"decoder: unrecognized frame kind", nil}
+}
+
+// readNamedTag reads a tag's type and (unless it is the compound end tag)
+// its name, then dispatches on the tag type. Used for the root tag and
+// for each member of a TAG_Compound.
+func (d *Decoder) readNamedTag() (Event, error) {
+	var tagType byte
+	err := binary.Read(d.r, d.byteOrder, &tagType)
+	if err != nil {
+		return Event{}, NbtParseError{"Reading TagType", err}
+	}
+	if tagType == 0 {
+		return Event{Type: EventTagEnd}, nil
+	}
+	nameLen, err := readNameLen(d.r, d.format, d.byteOrder)
+	if err != nil {
+		return Event{}, NbtParseError{"Reading Name length", err}
+	}
+	if err = checkCount(d.r, nameLen, 1); err != nil {
+		return Event{}, NbtParseError{"Reading Name length", err}
+	}
+	nameBytes := make([]byte, nameLen)
+	err = binary.Read(d.r, d.byteOrder, &nameBytes)
+	if err != nil {
+		return Event{}, NbtParseError{"Reading Name", err}
+	}
+	return d.dispatchTag(tagType, string(nameBytes), false)
+}
+
+// readListItem reads one payload-only item of a TAG_List, whose items
+// have no tagType/name header of their own.
+func (d *Decoder) readListItem(itemType byte) (Event, error) {
+	if itemType == 0 {
+		// An empty list is declared with item type TAG_End.
+		return Event{Type: EventPrimitive, TagType: 0, Bare: true}, nil
+	}
+	return d.dispatchTag(itemType, "", true)
+}
+
+// dispatchTag reads the payload for tagType, pushing a frame and
+// returning EventTagStart for containers, or reading and returning the
+// value directly for everything else.
+func (d *Decoder) dispatchTag(tagType byte, name string, bare bool) (Event, error) {
+	switch tagType {
+	case 9:
+		var listType byte
+		err := binary.Read(d.r, d.byteOrder, &listType)
+		if err != nil {
+			return Event{}, NbtParseError{"Reading list TagType", err}
+		}
+		count, err := readCount(d.r, d.format, d.byteOrder)
+		if err != nil {
+			return Event{}, NbtParseError{"Reading list tag length", err}
+		}
+		if err = checkCount(d.r, count, 1); err != nil {
+			return Event{}, NbtParseError{"Reading list tag length", err}
+		}
+		d.stack = append(d.stack, &decoderFrame{kind: frameList, itemType: listType, remaining: count})
+		return Event{Type: EventTagStart, TagType: 9, Name: name, Bare: bare, ListType: listType}, nil
+	case 10:
+		d.stack = append(d.stack, &decoderFrame{kind: frameCompound})
+		return Event{Type: EventTagStart, TagType: 10, Name: name, Bare: bare}, nil
+	case 7, 11:
+		count, err := readCount(d.r, d.format, d.byteOrder)
+		if err != nil {
+			return Event{}, NbtParseError{"Reading array tag length", err}
+		}
+		elementSize := int64(1)
+		if tagType == 11 {
+			elementSize = 4
+		}
+		if err = checkCount(d.r, count, elementSize); err != nil {
+			return Event{}, NbtParseError{"Reading array tag length", err}
+		}
+		frame := &decoderFrame{kind: frameArray, itemType: tagType, remaining: count, name: name, bare: bare}
+		d.stack = append(d.stack, frame)
+		return d.readArrayChunk(frame)
+	default:
+		value, err := readScalar(d.r, d.format, d.byteOrder, tagType)
+		if err != nil {
+			return Event{}, err
+		}
+		return Event{Type: EventPrimitive, TagType: tagType, Name: name, Bare: bare, Value: value}, nil
+	}
+}
+
+// readArrayChunk reads up to arrayChunkSize more elements of the array
+// tracked by frame, popping frame once it is exhausted.
+func (d *Decoder) readArrayChunk(frame *decoderFrame) (Event, error) {
+	chunkLen := frame.remaining
+	if chunkLen > arrayChunkSize {
+		chunkLen = arrayChunkSize
+	}
+	var value interface{}
+	if frame.itemType == 7 {
+		chunk := make([]byte, chunkLen)
+		if chunkLen > 0 {
+			if err := binary.Read(d.r, d.byteOrder, &chunk); err != nil {
+				return Event{}, NbtParseError{"Reading byte array chunk", err}
+			}
+		}
+		value = chunk
+	} else {
+		chunk := make([]int32, chunkLen)
+		if chunkLen > 0 {
+			if err := binary.Read(d.r, d.byteOrder, &chunk); err != nil {
+				return Event{}, NbtParseError{"Reading int array chunk", err}
+			}
+		}
+		value = chunk
+	}
+	first := !frame.emittedFirst
+	frame.emittedFirst = true
+	frame.remaining -= chunkLen
+	ev := Event{
+		Type:      EventArrayChunk,
+		TagType:   frame.itemType,
+		Name:      frame.name,
+		Bare:      frame.bare,
+		Value:     value,
+		First:     first,
+		Remaining: frame.remaining,
+	}
+	if frame.remaining == 0 {
+		d.stack = d.stack[:len(d.stack)-1]
+	}
+	return ev, nil
+}
+
+// readScalar reads the payload of any tag type other than TAG_List,
+// TAG_Compound, TAG_Byte_Array and TAG_Int_Array.
+func readScalar(r *bytes.Reader, format NbtFormat, byteOrder binary.ByteOrder, tagType byte) (interface{}, error) {
+	switch tagType {
+	case 1:
+		i, err := readInt(r, 1, byteOrder)
+		if err != nil {
+			return nil, NbtParseError{"Reading int8", err}
+		}
+		return i, nil
+	case 2:
+		i, err := readInt(r, 2, byteOrder)
+		if err != nil {
+			return nil, NbtParseError{"Reading int16", err}
+		}
+		return i, nil
+	case 3:
+		i, err := readTagInt(r, format, byteOrder)
+		if err != nil {
+			return nil, NbtParseError{"Reading int32", err}
+		}
+		return i, nil
+	case 4:
+		i, err := readTagLong(r, format, byteOrder)
+		if err != nil {
+			return nil, NbtParseError{"Reading int64", err}
+		}
+		return i, nil
+	case 5:
+		var f float32
+		if err := binary.Read(r, byteOrder, &f); err != nil {
+			return nil, NbtParseError{"Reading float32", err}
+		}
+		return f, nil
+	case 6:
+		var f float64
+		if err := binary.Read(r, byteOrder, &f); err != nil {
+			return nil, NbtParseError{"Reading float64", err}
+		}
+		return f, nil
+	case 8:
+		strLen, err := readNameLen(r, format, byteOrder)
+		if err != nil {
+			return nil, NbtParseError{"Reading string tag length", err}
+		}
+		if err = checkCount(r, strLen, 1); err != nil {
+			return nil, NbtParseError{"Reading string tag length", err}
+		}
+		utf8String := make([]byte, strLen)
+		if err := binary.Read(r, byteOrder, &utf8String); err != nil {
+			return nil, NbtParseError{"Reading string tag data", err}
+		}
+		return string(utf8String), nil
+	default:
+		return nil, NbtParseError{"TagType not recognized", nil}
+	}
+}
+
+// jsonFrame tracks what EncodeEventsToJSON must write to close out a
+// container (TAG_List, TAG_Compound, or an in-progress array value).
+type jsonFrame struct {
+	tagType byte
+	wrapped bool // whether a {"tagType":..,"name":..,"value": wrapper needs closing
+	first   bool // true until this container's first child has been written
+	b64     io.WriteCloser
+}
+
+// EncodeEventsToJSON drains d and writes the equivalent of Nbt2Json's
+// output, rendered for d's JsonOutputMode, to w, holding only a small
+// frame for each open container in memory rather than the whole tag
+// tree. Unlike Nbt2Json it writes compact, not indented, JSON; it writes
+// an empty list or compound as [] where Nbt2Json writes null, and gives
+// a root TAG_End a null value where Nbt2Json omits the value.
+func EncodeEventsToJSON(d *Decoder, w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	var stack []*jsonFrame
+
+	writeComma := func() error {
+		if len(stack) == 0 {
+			return nil
+		}
+		top := stack[len(stack)-1]
+		if !top.first {
+			if _, err := bw.WriteString(","); err != nil {
+				return err
+			}
+		}
+		top.first = false
+		return nil
+	}
+
+	for {
+		ev, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		switch ev.Type {
+		case EventTagEnd:
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if top.tagType == 9 {
+				if _, err := bw.WriteString("]}"); err != nil {
+					return err
+				}
+			} else {
+				if _, err := bw.WriteString("]"); err != nil {
+					return err
+				}
+			}
+			if top.wrapped {
+				if _, err := bw.WriteString("}"); err != nil {
+					return err
+				}
+			}
+
+		case EventTagStart:
+			if err := writeComma(); err != nil {
+				return err
+			}
+			if !ev.Bare {
+				if err := writeTagPrefix(bw, ev.TagType, ev.Name); err != nil {
+					return err
+				}
+			}
+			if ev.TagType == 9 {
+				if _, err := bw.WriteString("{\"tagListType\":"); err != nil {
+					return err
+				}
+				if err := writeJSONValue(bw, ev.ListType); err != nil {
+					return err
+				}
+				if _, err := bw.WriteString(",\"list\":["); err != nil {
+					return err
+				}
+			} else {
+				if _, err := bw.WriteString("["); err != nil {
+					return err
+				}
+			}
+			stack = append(stack, &jsonFrame{tagType: ev.TagType, wrapped: !ev.Bare, first: true})
+
+		case EventPrimitive:
+			if err := writeComma(); err != nil {
+				return err
+			}
+			if !ev.Bare {
+				if err := writeTagPrefix(bw, ev.TagType, ev.Name); err != nil {
+					return err
+				}
+			}
+			value := ev.Value
+			if ev.Bare {
+				value = formatListItem(ev.TagType, value, d.mode)
+			}
+			if err := writeJSONValue(bw, value); err != nil {
+				return err
+			}
+			if !ev.Bare {
+				if _, err := bw.WriteString("}"); err != nil {
+					return err
+				}
+			}
+
+		case EventArrayChunk:
+			if err := writeArrayChunk(bw, &stack, ev, d.mode); err != nil {
+				return err
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// writeTagPrefix writes the opening `{"tagType":T,"name":"N","value":`
+// of a named tag.
+func writeTagPrefix(bw *bufio.Writer, tagType byte, name string) error {
+	if _, err := bw.WriteString("{\"tagType\":"); err != nil {
+		return err
+	}
+	if err := writeJSONValue(bw, tagType); err != nil {
+		return err
+	}
+	if _, err := bw.WriteString(",\"name\":"); err != nil {
+		return err
+	}
+	if err := writeJSONValue(bw, name); err != nil {
+		return err
+	}
+	if _, err := bw.WriteString(",\"value\":"); err != nil {
+		return err
+	}
+	return nil
+}
+
+// writeJSONValue marshals value with encoding/json and writes it to bw.
+func writeJSONValue(bw *bufio.Writer, value interface{}) error {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	_, err = bw.Write(b)
+	return err
+}
+
+// writeArrayChunk streams one EventArrayChunk into bw. Under Pretty, a
+// byte array is base64-encoded incrementally via base64.NewEncoder to
+// match Nbt2Json's []byte JSON encoding; under Lossless it is written as
+// a JSON array of "Nb"-suffixed strings instead, like formatByteArray.
+// An int array is always written as a plain JSON number array.
+func writeArrayChunk(bw *bufio.Writer, stackPtr *[]*jsonFrame, ev Event, mode JsonOutputMode) error {
+	stack := *stackPtr
+	if ev.First {
+		if len(stack) > 0 {
+			enclosing := stack[len(stack)-1]
+			if !enclosing.first {
+				if _, err := bw.WriteString(","); err != nil {
+					return err
+				}
+			}
+			enclosing.first = false
+		}
+		if !ev.Bare {
+			if err := writeTagPrefix(bw, ev.TagType, ev.Name); err != nil {
+				return err
+			}
+		}
+		frame := &jsonFrame{tagType: ev.TagType, wrapped: !ev.Bare, first: true}
+		if ev.TagType == 7 && mode != Lossless {
+			if _, err := bw.WriteString("\""); err != nil {
+				return err
+			}
+			frame.b64 = base64.NewEncoder(base64.StdEncoding, bw)
+		} else {
+			if _, err := bw.WriteString("["); err != nil {
+				return err
+			}
+		}
+		*stackPtr = append(stack, frame)
+		stack = *stackPtr
+	}
+	top := stack[len(stack)-1]
+	if err := writeArrayValues(bw, top, ev.Value, ev.First); err != nil {
+		return err
+	}
+	if ev.Remaining == 0 {
+		*stackPtr = stack[:len(stack)-1]
+		if top.b64 != nil {
+			if err := top.b64.Close(); err != nil {
+				return err
+			}
+			if _, err := bw.WriteString("\""); err != nil {
+				return err
+			}
+		} else {
+			if _, err := bw.WriteString("]"); err != nil {
+				return err
+			}
+		}
+		if top.wrapped {
+			if _, err := bw.WriteString("}"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeArrayValues writes one chunk's worth of array elements: raw bytes
+// into the open base64 stream for a Pretty byte array, comma-separated
+// "Nb"-suffixed strings for a Lossless byte array (frame.b64 is nil in
+// that case), or comma-separated JSON numbers for an int array.
+func writeArrayValues(bw *bufio.Writer, frame *jsonFrame, value interface{}, firstChunk bool) error {
+	switch v := value.(type) {
+	case []byte:
+		if frame.b64 != nil {
+			_, err := frame.b64.Write(v)
+			return err
+		}
+		for i, b := range v {
+			if !firstChunk || i > 0 {
+				if _, err := bw.WriteString(","); err != nil {
+					return err
+				}
+			}
+			if err := writeJSONValue(bw, formatByteArrayItem(b)); err != nil {
+				return err
+			}
+		}
+	case []int32:
+		for i, n := range v {
+			if !firstChunk || i > 0 {
+				if _, err := bw.WriteString(","); err != nil {
+					return err
+				}
+			}
+			if err := writeJSONValue(bw, n); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// StreamNbt2Json decodes r as NBT and writes JSON equivalent to
+// Nbt2Json's output for the given mode to w, via Decoder and
+// EncodeEventsToJSON, so large files can be converted with bounded
+// memory use instead of buffering the whole tag tree.
+func StreamNbt2Json(r *bytes.Reader, byteOrder binary.ByteOrder, format NbtFormat, mode JsonOutputMode, w io.Writer) error {
+	d := NewDecoder(r, byteOrder, format, mode)
+	return EncodeEventsToJSON(d, w)
+}
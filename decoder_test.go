@@ -0,0 +1,65 @@
+package nbt2json
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// TestDecoderNameLengthTruncated is the streaming-decoder counterpart of
+// TestNbt2JsonNameLengthTruncated: readNamedTag must reject an
+// attacker-controlled name length that exceeds available data rather
+// than allocating it straight away.
+func TestDecoderNameLengthTruncated(t *testing.T) {
+	r := bytes.NewReader(bedrockHugeNameLenInput())
+	d := NewDecoder(r, binary.LittleEndian, BedrockNetwork, Pretty)
+	if _, err := d.Token(); err != nil {
+		t.Fatalf("reading root compound: %v", err)
+	}
+	if _, err := d.Token(); err == nil {
+		t.Fatal("expected error for name length exceeding available data, got nil")
+	}
+}
+
+// TestReadScalarStringLengthTruncated is the decoder.go counterpart of
+// TestGetPayloadStringLengthTruncated.
+func TestReadScalarStringLengthTruncated(t *testing.T) {
+	hugeLen := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(hugeLen, 1<<40)
+	r := bytes.NewReader(hugeLen[:n])
+	_, err := readScalar(r, BedrockNetwork, binary.LittleEndian, 8)
+	if err == nil {
+		t.Fatal("expected error for string length exceeding available data, got nil")
+	}
+}
+
+// TestStreamNbt2JsonLosslessMatchesNbt2Json checks that StreamNbt2Json
+// under mode Lossless renders a list of byte arrays the same way the
+// non-streaming Nbt2Json does, rather than always falling back to
+// Pretty's base64/plain-value shape.
+func TestStreamNbt2JsonLosslessMatchesNbt2Json(t *testing.T) {
+	data := encodeByteArrayList([][]byte{{1, 2, 3}, {9, 8}})
+
+	want, err := Nbt2Json(bytes.NewReader(data), binary.BigEndian, Java, Lossless)
+	if err != nil {
+		t.Fatalf("Nbt2Json returned error: %v", err)
+	}
+
+	var got bytes.Buffer
+	if err := StreamNbt2Json(bytes.NewReader(data), binary.BigEndian, Java, Lossless, &got); err != nil {
+		t.Fatalf("StreamNbt2Json returned error: %v", err)
+	}
+
+	var wantVal, gotVal interface{}
+	if err := json.Unmarshal(want, &wantVal); err != nil {
+		t.Fatalf("unmarshaling Nbt2Json output: %v", err)
+	}
+	if err := json.Unmarshal(got.Bytes(), &gotVal); err != nil {
+		t.Fatalf("unmarshaling StreamNbt2Json output: %v", err)
+	}
+	if !reflect.DeepEqual(wantVal, gotVal) {
+		t.Fatalf("StreamNbt2Json output differs from Nbt2Json:\nNbt2Json:       %s\nStreamNbt2Json: %s", want, got.Bytes())
+	}
+}
@@ -0,0 +1,120 @@
+package nbt2json
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// NbtFormat selects the on-disk encoding variant of the NBT stream being
+// read or written, since Minecraft Bedrock Edition diverges from the
+// original Java Edition format in how lengths and integers are packed.
+type NbtFormat int
+
+const (
+	// Java is the original big-endian NBT format used by Java Edition,
+	// and by Bedrock's level.dat when paired with binary.LittleEndian.
+	Java NbtFormat = iota
+	// BedrockLE is Bedrock's little-endian NBT, used for level.dat and
+	// structure files. Pair it with binary.LittleEndian.
+	BedrockLE
+	// BedrockNetwork is the NBT variant Bedrock sends over the network:
+	// TAG_Int, TAG_Long, string lengths and array counts are zig-zag/
+	// unsigned varints, while floats and doubles stay fixed little-endian.
+	BedrockNetwork
+)
+
+// readNameLen reads the 2-byte (Java/BedrockLE) or unsigned varint
+// (BedrockNetwork) length that precedes a tag name or string value.
+func readNameLen(r *bytes.Reader, format NbtFormat, byteOrder binary.ByteOrder) (int64, error) {
+	if format == BedrockNetwork {
+		length, err := binary.ReadUvarint(r)
+		if err != nil {
+			return 0, NbtParseError{"Reading varint name/string length", err}
+		}
+		return int64(length), nil
+	}
+	return readInt(r, 2, byteOrder)
+}
+
+// readCount reads a 4-byte (Java/BedrockLE) or zig-zag varint
+// (BedrockNetwork) count, used for byte/int array and list lengths.
+func readCount(r *bytes.Reader, format NbtFormat, byteOrder binary.ByteOrder) (int64, error) {
+	if format == BedrockNetwork {
+		count, err := binary.ReadVarint(r)
+		if err != nil {
+			return 0, NbtParseError{"Reading varint count", err}
+		}
+		return count, nil
+	}
+	return readInt(r, 4, byteOrder)
+}
+
+// readTagInt reads a TAG_Int payload: a zig-zag varint32 under
+// BedrockNetwork, otherwise a fixed-width int in byteOrder.
+func readTagInt(r *bytes.Reader, format NbtFormat, byteOrder binary.ByteOrder) (int64, error) {
+	if format == BedrockNetwork {
+		i, err := binary.ReadVarint(r)
+		if err != nil {
+			return 0, NbtParseError{"Reading varint int32", err}
+		}
+		return i, nil
+	}
+	return readInt(r, 4, byteOrder)
+}
+
+// readTagLong reads a TAG_Long payload: a zig-zag varint64 under
+// BedrockNetwork, otherwise a fixed-width int in byteOrder.
+func readTagLong(r *bytes.Reader, format NbtFormat, byteOrder binary.ByteOrder) (int64, error) {
+	if format == BedrockNetwork {
+		i, err := binary.ReadVarint(r)
+		if err != nil {
+			return 0, NbtParseError{"Reading varint int64", err}
+		}
+		return i, nil
+	}
+	return readInt(r, 8, byteOrder)
+}
+
+// writeNameLen is the inverse of readNameLen.
+func writeNameLen(buf *bytes.Buffer, length int64, format NbtFormat, byteOrder binary.ByteOrder) error {
+	if format == BedrockNetwork {
+		tmp := make([]byte, binary.MaxVarintLen64)
+		n := binary.PutUvarint(tmp, uint64(length))
+		_, err := buf.Write(tmp[:n])
+		return err
+	}
+	return writeInt(buf, length, 2, byteOrder)
+}
+
+// writeCount is the inverse of readCount.
+func writeCount(buf *bytes.Buffer, count int64, format NbtFormat, byteOrder binary.ByteOrder) error {
+	if format == BedrockNetwork {
+		tmp := make([]byte, binary.MaxVarintLen64)
+		n := binary.PutVarint(tmp, count)
+		_, err := buf.Write(tmp[:n])
+		return err
+	}
+	return writeInt(buf, count, 4, byteOrder)
+}
+
+// writeTagInt is the inverse of readTagInt.
+func writeTagInt(buf *bytes.Buffer, i int64, format NbtFormat, byteOrder binary.ByteOrder) error {
+	if format == BedrockNetwork {
+		tmp := make([]byte, binary.MaxVarintLen64)
+		n := binary.PutVarint(tmp, i)
+		_, err := buf.Write(tmp[:n])
+		return err
+	}
+	return writeInt(buf, i, 4, byteOrder)
+}
+
+// writeTagLong is the inverse of readTagLong.
+func writeTagLong(buf *bytes.Buffer, i int64, format NbtFormat, byteOrder binary.ByteOrder) error {
+	if format == BedrockNetwork {
+		tmp := make([]byte, binary.MaxVarintLen64)
+		n := binary.PutVarint(tmp, i)
+		_, err := buf.Write(tmp[:n])
+		return err
+	}
+	return writeInt(buf, i, 8, byteOrder)
+}
@@ -0,0 +1,34 @@
+package nbt2json
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// FuzzNbt2Json feeds arbitrary byte streams into Nbt2Json, the raw-stream
+// entry point checkCount's length checks guard, across every NbtFormat
+// and JsonOutputMode. Corrupt input should only ever come back as an
+// error: it must never panic or allocate without bound, which is exactly
+// what the crafted name-length seed below once did.
+func FuzzNbt2Json(f *testing.F) {
+	f.Add([]byte{10, 0, 0, 0}) // empty compound
+	f.Add(encodeByteArrayList([][]byte{{1, 2, 3}}))
+	f.Add(bedrockHugeNameLenInput())
+
+	formats := []struct {
+		format    NbtFormat
+		byteOrder binary.ByteOrder
+	}{
+		{Java, binary.BigEndian},
+		{BedrockLE, binary.LittleEndian},
+		{BedrockNetwork, binary.LittleEndian},
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		for _, fo := range formats {
+			for _, mode := range []JsonOutputMode{Pretty, Lossless} {
+				Nbt2Json(bytes.NewReader(data), fo.byteOrder, fo.format, mode)
+			}
+		}
+	})
+}
@@ -0,0 +1,244 @@
+package nbt2json
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+)
+
+// rawNbtTag mirrors NbtTag but keeps Value as a json.RawMessage so the
+// payload can be decoded according to TagType rather than guessed from
+// its JSON shape.
+type rawNbtTag struct {
+	TagType byte            `json:"tagType"`
+	Name    string          `json:"name"`
+	Value   json.RawMessage `json:"value,omitempty"`
+}
+
+// rawNbtTagList mirrors NbtTagList with its items left undecoded.
+type rawNbtTagList struct {
+	TagListType byte              `json:"tagListType"`
+	List        []json.RawMessage `json:"list"`
+}
+
+// Json2Nbt takes JSON produced by Nbt2Json and re-encodes it as an NBT byte
+// stream. It is the inverse of Nbt2Json, letting callers edit NBT data as
+// JSON and write the result back out. mode must match the JsonOutputMode
+// jsonBytes was produced with, so suffixed Lossless list items and byte
+// arrays are parsed back to their original values rather than Pretty's
+// plain ones.
+func Json2Nbt(jsonBytes []byte, byteOrder binary.ByteOrder, format NbtFormat, mode JsonOutputMode) ([]byte, error) {
+	var tag rawNbtTag
+	err := json.Unmarshal(jsonBytes, &tag)
+	if err != nil {
+		return nil, NbtParseError{"Unmarshaling JSON tag", err}
+	}
+	var buf bytes.Buffer
+	err = writeTag(&buf, byteOrder, format, mode, tag)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeTag writes a tag's type, name (unless it is the compound end tag)
+// and payload to buf.
+func writeTag(buf *bytes.Buffer, byteOrder binary.ByteOrder, format NbtFormat, mode JsonOutputMode, tag rawNbtTag) error {
+	err := binary.Write(buf, byteOrder, tag.TagType)
+	if err != nil {
+		return NbtParseError{"Writing TagType", err}
+	}
+	if tag.TagType != 0 {
+		nameBytes := []byte(tag.Name)
+		err = writeNameLen(buf, int64(len(nameBytes)), format, byteOrder)
+		if err != nil {
+			return NbtParseError{"Writing Name length", err}
+		}
+		_, err = buf.Write(nameBytes)
+		if err != nil {
+			return NbtParseError{"Writing Name", err}
+		}
+	}
+	// false: tag.Value is a tag's own payload, never a list item.
+	return writePayload(buf, byteOrder, format, mode, tag.TagType, tag.Value, false)
+}
+
+// writeInt writes the low numBytes bytes of value in byteOrder, the
+// inverse of readInt.
+func writeInt(buf *bytes.Buffer, value int64, numBytes int, byteOrder binary.ByteOrder) error {
+	full := make([]byte, 8)
+	binary.BigEndian.PutUint64(full, uint64(value))
+	be := full[8-numBytes:]
+	if byteOrder == binary.BigEndian {
+		_, err := buf.Write(be)
+		return err
+	} else if byteOrder == binary.LittleEndian {
+		le := make([]byte, numBytes)
+		for i, b := range be {
+			le[numBytes-1-i] = b
+		}
+		_, err := buf.Write(le)
+		return err
+	}
+	return NbtParseError{"byteOrder not recognized", nil}
+}
+
+// writePayload writes the value for tagType, the inverse of getPayload.
+// listItem is true when value is a TAG_List item rather than a tag's own
+// payload: under mode Lossless, formatListItem gives list items of
+// numeric tagTypes an SNBT-style suffix that a tag's own value never
+// carries, so writePayload must know which shape to expect. A byte array
+// is suffixed under Lossless regardless of listItem, matching
+// formatByteArray/formatListItem.
+func writePayload(buf *bytes.Buffer, byteOrder binary.ByteOrder, format NbtFormat, mode JsonOutputMode, tagType byte, value json.RawMessage, listItem bool) error {
+	switch tagType {
+	case 0:
+		// end tag for compound; no payload
+	case 1, 2, 3, 4:
+		var i int64
+		var err error
+		if mode == Lossless && listItem {
+			var suffix byte
+			switch tagType {
+			case 1:
+				suffix = 'b'
+			case 2:
+				suffix = 's'
+			case 4:
+				suffix = 'L'
+			}
+			i, err = parseSuffixedInt(value, suffix)
+		} else {
+			err = json.Unmarshal(value, &i)
+		}
+		if err != nil {
+			return NbtParseError{"Unmarshaling intxx", err}
+		}
+		if tagType == 3 {
+			return writeTagInt(buf, i, format, byteOrder)
+		}
+		if tagType == 4 {
+			return writeTagLong(buf, i, format, byteOrder)
+		}
+		numBytes := 1
+		if tagType == 2 {
+			numBytes = 2
+		}
+		return writeInt(buf, i, numBytes, byteOrder)
+	case 5:
+		var f float64
+		var err error
+		if mode == Lossless && listItem {
+			f, err = parseSuffixedFloat(value, 'f', 32)
+		} else {
+			err = json.Unmarshal(value, &f)
+		}
+		if err != nil {
+			return NbtParseError{"Unmarshaling float32", err}
+		}
+		return binary.Write(buf, byteOrder, float32(f))
+	case 6:
+		var f float64
+		var err error
+		if mode == Lossless && listItem {
+			f, err = parseSuffixedFloat(value, 'd', 64)
+		} else {
+			err = json.Unmarshal(value, &f)
+		}
+		if err != nil {
+			return NbtParseError{"Unmarshaling float64", err}
+		}
+		return binary.Write(buf, byteOrder, f)
+	case 7:
+		var byteArray []byte
+		var err error
+		if mode == Lossless {
+			byteArray, err = parseLosslessByteArray(value)
+		} else {
+			var encoded string
+			if err = json.Unmarshal(value, &encoded); err == nil {
+				byteArray, err = base64.StdEncoding.DecodeString(encoded)
+			}
+		}
+		if err != nil {
+			return NbtParseError{"Unmarshaling byte array", err}
+		}
+		err = writeCount(buf, int64(len(byteArray)), format, byteOrder)
+		if err != nil {
+			return NbtParseError{"Writing byte array tag length", err}
+		}
+		_, err = buf.Write(byteArray)
+		return err
+	case 8:
+		var s string
+		err := json.Unmarshal(value, &s)
+		if err != nil {
+			return NbtParseError{"Unmarshaling string", err}
+		}
+		utf8String := []byte(s)
+		err = writeNameLen(buf, int64(len(utf8String)), format, byteOrder)
+		if err != nil {
+			return NbtParseError{"Writing string tag length", err}
+		}
+		_, err = buf.Write(utf8String)
+		return err
+	case 9:
+		var tagList rawNbtTagList
+		err := json.Unmarshal(value, &tagList)
+		if err != nil {
+			return NbtParseError{"Unmarshaling list", err}
+		}
+		err = binary.Write(buf, byteOrder, tagList.TagListType)
+		if err != nil {
+			return NbtParseError{"Writing list TagType", err}
+		}
+		err = writeCount(buf, int64(len(tagList.List)), format, byteOrder)
+		if err != nil {
+			return NbtParseError{"Writing list tag length", err}
+		}
+		for _, item := range tagList.List {
+			err = writePayload(buf, byteOrder, format, mode, tagList.TagListType, item, true)
+			if err != nil {
+				return NbtParseError{"Writing list tag item", err}
+			}
+		}
+	case 10:
+		var compound []json.RawMessage
+		err := json.Unmarshal(value, &compound)
+		if err != nil {
+			return NbtParseError{"Unmarshaling compound", err}
+		}
+		for _, rawChild := range compound {
+			var child rawNbtTag
+			err = json.Unmarshal(rawChild, &child)
+			if err != nil {
+				return NbtParseError{"Unmarshaling compound child", err}
+			}
+			err = writeTag(buf, byteOrder, format, mode, child)
+			if err != nil {
+				return err
+			}
+		}
+		return binary.Write(buf, byteOrder, byte(0))
+	case 11:
+		var intArray []int32
+		err := json.Unmarshal(value, &intArray)
+		if err != nil {
+			return NbtParseError{"Unmarshaling int array", err}
+		}
+		err = writeCount(buf, int64(len(intArray)), format, byteOrder)
+		if err != nil {
+			return NbtParseError{"Writing int array tag length", err}
+		}
+		for _, oneInt := range intArray {
+			err = binary.Write(buf, byteOrder, oneInt)
+			if err != nil {
+				return NbtParseError{"Writing int in int array tag", err}
+			}
+		}
+	default:
+		return NbtParseError{"TagType not recognized", nil}
+	}
+	return nil
+}
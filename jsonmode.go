@@ -0,0 +1,131 @@
+package nbt2json
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// JsonOutputMode selects how Nbt2Json renders tag list items and byte
+// arrays, which would otherwise lose type information once they leave
+// their NbtTag/NbtTagList wrapper.
+type JsonOutputMode int
+
+const (
+	// Pretty is Nbt2Json's original behavior: list items are plain JSON
+	// values and byte arrays are base64 strings, simple to read but
+	// unable to distinguish e.g. a TAG_Byte 5 from a TAG_Int 5 once it
+	// is nested in a list.
+	Pretty JsonOutputMode = iota
+	// Lossless suffixes numeric list items the way SNBT does ("5b",
+	// "5s", "5L", "1.5f", "1.5d", bare for TAG_Int) and renders byte
+	// arrays as an array of "Nb"-suffixed strings instead of base64, so
+	// a JSON edit round-tripped through Json2Nbt keeps its tag types.
+	Lossless
+)
+
+// formatByteArray renders a TAG_Byte_Array payload for the given output
+// mode. Under Pretty it is left as []byte, which encoding/json marshals
+// as a base64 string; under Lossless it becomes a []string of
+// SNBT-style "Nb" entries.
+func formatByteArray(byteArray []byte, mode JsonOutputMode) interface{} {
+	if mode != Lossless {
+		return byteArray
+	}
+	out := make([]string, len(byteArray))
+	for i, b := range byteArray {
+		out[i] = formatByteArrayItem(b)
+	}
+	return out
+}
+
+// formatByteArrayItem renders a single TAG_Byte_Array element the way
+// formatByteArray does under Lossless, for callers (Decoder's streaming
+// encoder) that write one array element at a time instead of a whole
+// []byte.
+func formatByteArrayItem(b byte) string {
+	return fmt.Sprintf("%db", b)
+}
+
+// formatListItem renders one TAG_List item for the given output mode.
+// Under Pretty it is returned unchanged. Under Lossless, numeric
+// scalars and byte arrays are given SNBT-style type suffixes so the
+// item's tag type survives a JSON edit; strings, nested lists and
+// compounds are unambiguous already and are left unchanged.
+//
+// getPayload returns a TAG_Byte_Array payload as a raw []byte
+// regardless of mode, whether it is a list item (here) or a tag's own
+// value (formatted once in Nbt2Json instead), so this is the only place
+// a byte array list item is run through formatByteArray.
+func formatListItem(tagListType byte, payload interface{}, mode JsonOutputMode) interface{} {
+	if mode != Lossless {
+		return payload
+	}
+	switch tagListType {
+	case 1:
+		return fmt.Sprintf("%db", payload.(int64))
+	case 2:
+		return fmt.Sprintf("%ds", payload.(int64))
+	case 3:
+		return fmt.Sprintf("%d", payload.(int64))
+	case 4:
+		return fmt.Sprintf("%dL", payload.(int64))
+	case 5:
+		return fmt.Sprintf("%gf", payload.(float32))
+	case 6:
+		return fmt.Sprintf("%gd", payload.(float64))
+	case 7:
+		return formatByteArray(payload.([]byte), mode)
+	default:
+		return payload
+	}
+}
+
+// stripSuffix removes suffix from the end of s if present. suffix of 0
+// means s carries no suffix (TAG_Int list items) and is returned as-is.
+func stripSuffix(s string, suffix byte) string {
+	if suffix == 0 || !strings.HasSuffix(s, string(suffix)) {
+		return s
+	}
+	return s[:len(s)-1]
+}
+
+// parseSuffixedInt reverses the "%db"/"%ds"/"%d"/"%dL" formatting
+// formatListItem applies to int8/int16/int32/int64 list items under
+// Lossless, and to a single byte of a Lossless byte array.
+func parseSuffixedInt(value json.RawMessage, suffix byte) (int64, error) {
+	var s string
+	if err := json.Unmarshal(value, &s); err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(stripSuffix(s, suffix), 10, 64)
+}
+
+// parseSuffixedFloat reverses the "%gf"/"%gd" formatting formatListItem
+// applies to float32/float64 list items under Lossless.
+func parseSuffixedFloat(value json.RawMessage, suffix byte, bitSize int) (float64, error) {
+	var s string
+	if err := json.Unmarshal(value, &s); err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(stripSuffix(s, suffix), bitSize)
+}
+
+// parseLosslessByteArray reverses formatByteArray's Lossless rendering,
+// turning a []string of "Nb" entries back into a []byte.
+func parseLosslessByteArray(value json.RawMessage) ([]byte, error) {
+	var entries []string
+	if err := json.Unmarshal(value, &entries); err != nil {
+		return nil, err
+	}
+	out := make([]byte, len(entries))
+	for i, entry := range entries {
+		n, err := strconv.ParseInt(stripSuffix(entry, 'b'), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = byte(n)
+	}
+	return out, nil
+}
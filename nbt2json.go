@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
 )
 
@@ -34,6 +35,25 @@ func (e NbtParseError) Error() string {
 	return fmt.Sprintf("Error parsing NBT: %s%s", e.s, s)
 }
 
+// ErrTruncated is returned (wrapped in an NbtParseError) when a declared
+// byte/int array or list length is negative, or larger than the data
+// remaining in the reader, so a corrupt or malicious length field is
+// rejected instead of allocating or looping on it.
+var ErrTruncated = errors.New("nbt2json: length exceeds available data")
+
+// checkCount rejects a negative count, or one that declares more
+// elements of elementSize bytes each than remain in r, before any
+// allocation is made for it.
+func checkCount(r *bytes.Reader, count int64, elementSize int64) error {
+	if count < 0 {
+		return NbtParseError{"length is negative", ErrTruncated}
+	}
+	if count > int64(r.Len())/elementSize {
+		return NbtParseError{"length exceeds available data", ErrTruncated}
+	}
+	return nil
+}
+
 // Reads 0-8 bytes and returns an int64 value
 func readInt(r *bytes.Reader, numBytes int, byteOrder binary.ByteOrder) (i int64, err error) {
 	var myInt64 []byte
@@ -56,8 +76,13 @@ func readInt(r *bytes.Reader, numBytes int, byteOrder binary.ByteOrder) (i int64
 	return i, err
 }
 
-// Nbt2Json ...
-func Nbt2Json(r *bytes.Reader, byteOrder binary.ByteOrder) ([]byte, error) {
+// Nbt2Json reads a single NBT tag (and, recursively, any tag it contains)
+// from r and returns it as JSON. format selects the Java or Bedrock
+// encoding of lengths and integers; byteOrder selects the byte order,
+// which is big-endian for Java Edition and little-endian for Bedrock.
+// mode selects whether list items and byte arrays are rendered plainly
+// or with type-preserving suffixes; see JsonOutputMode.
+func Nbt2Json(r *bytes.Reader, byteOrder binary.ByteOrder, format NbtFormat, mode JsonOutputMode) ([]byte, error) {
 	var data NbtTag
 	err := binary.Read(r, byteOrder, &data.TagType)
 	if err != nil {
@@ -67,10 +92,13 @@ func Nbt2Json(r *bytes.Reader, byteOrder binary.ByteOrder) ([]byte, error) {
 	if data.TagType != 0 {
 		var err error
 		var nameLen int64
-		nameLen, err = readInt(r, 2, byteOrder)
+		nameLen, err = readNameLen(r, format, byteOrder)
 		if err != nil {
 			return nil, NbtParseError{"Reading Name length", err}
 		}
+		if err = checkCount(r, nameLen, 1); err != nil {
+			return nil, NbtParseError{"Reading Name length", err}
+		}
 		name := make([]byte, nameLen)
 		err = binary.Read(r, byteOrder, &name)
 		if err != nil {
@@ -78,16 +106,22 @@ func Nbt2Json(r *bytes.Reader, byteOrder binary.ByteOrder) ([]byte, error) {
 		}
 		data.Name = string(name[:])
 	}
-	data.Value, err = getPayload(r, byteOrder, data.TagType)
+	data.Value, err = getPayload(r, byteOrder, format, mode, data.TagType)
 	if err != nil {
 		return nil, err
 	}
+	// A list item's byte array payload is formatted once by
+	// formatListItem; a tag's own byte array payload (here) is never
+	// passed through formatListItem, so it must be formatted here instead.
+	if data.TagType == 7 {
+		data.Value = formatByteArray(data.Value.([]byte), mode)
+	}
 	outJson, err := json.MarshalIndent(data, "", "  ")
 	return outJson, nil
 }
 
 // Gets the tag payload. Had to break this out from the main function to allow tag list recursion
-func getPayload(r *bytes.Reader, byteOrder binary.ByteOrder, tagType byte) (interface{}, error) {
+func getPayload(r *bytes.Reader, byteOrder binary.ByteOrder, format NbtFormat, mode JsonOutputMode, tagType byte) (interface{}, error) {
 	var output interface{}
 	var err error
 	switch tagType {
@@ -104,12 +138,12 @@ func getPayload(r *bytes.Reader, byteOrder binary.ByteOrder, tagType byte) (inte
 			return nil, NbtParseError{"Reading int16", err}
 		}
 	case 3:
-		output, err = readInt(r, 4, byteOrder)
+		output, err = readTagInt(r, format, byteOrder)
 		if err != nil {
 			return nil, NbtParseError{"Reading int32", err}
 		}
 	case 4:
-		output, err = readInt(r, 8, byteOrder)
+		output, err = readTagLong(r, format, byteOrder)
 		if err != nil {
 			return nil, NbtParseError{"Reading int64", err}
 		}
@@ -128,25 +162,31 @@ func getPayload(r *bytes.Reader, byteOrder binary.ByteOrder, tagType byte) (inte
 		}
 		output = f
 	case 7:
-		var byteArray []byte
-		var oneByte byte
-		numRecords, err := readInt(r, 4, byteOrder)
+		numRecords, err := readCount(r, format, byteOrder)
 		if err != nil {
 			return nil, NbtParseError{"Reading byte array tag length", err}
 		}
-		for i := int64(1); i <= numRecords; i++ {
-			err := binary.Read(r, byteOrder, &oneByte)
+		if err = checkCount(r, numRecords, 1); err != nil {
+			return nil, NbtParseError{"Reading byte array tag length", err}
+		}
+		byteArray := make([]byte, numRecords)
+		if numRecords > 0 {
+			err = binary.Read(r, byteOrder, &byteArray)
 			if err != nil {
-				return nil, NbtParseError{"Reading byte in byte array tag", err}
+				return nil, NbtParseError{"Reading byte array tag data", err}
 			}
-			byteArray = append(byteArray, oneByte)
 		}
+		// formatted by the caller: Nbt2Json for a directly-read tag, or
+		// formatListItem for a tag list item (see case 9 below).
 		output = byteArray
 	case 8:
-		strLen, err := readInt(r, 2, byteOrder)
+		strLen, err := readNameLen(r, format, byteOrder)
 		if err != nil {
 			return nil, NbtParseError{"Reading string tag length", err}
 		}
+		if err = checkCount(r, strLen, 1); err != nil {
+			return nil, NbtParseError{"Reading string tag length", err}
+		}
 		utf8String := make([]byte, strLen)
 		err = binary.Read(r, byteOrder, &utf8String)
 		if err != nil {
@@ -159,16 +199,19 @@ func getPayload(r *bytes.Reader, byteOrder binary.ByteOrder, tagType byte) (inte
 		if err != nil {
 			return nil, NbtParseError{"Reading TagType", err}
 		}
-		numRecords, err := readInt(r, 4, byteOrder)
+		numRecords, err := readCount(r, format, byteOrder)
 		if err != nil {
 			return nil, NbtParseError{"Reading list tag length", err}
 		}
+		if err = checkCount(r, numRecords, 1); err != nil {
+			return nil, NbtParseError{"Reading list tag length", err}
+		}
 		for i := int64(1); i <= numRecords; i++ {
-			payload, err := getPayload(r, byteOrder, tagList.TagListType)
+			payload, err := getPayload(r, byteOrder, format, mode, tagList.TagListType)
 			if err != nil {
 				return nil, NbtParseError{"Reading list tag item", err}
 			}
-			tagList.List = append(tagList.List, payload)
+			tagList.List = append(tagList.List, formatListItem(tagList.TagListType, payload, mode))
 		}
 		output = tagList
 	case 10:
@@ -182,7 +225,7 @@ func getPayload(r *bytes.Reader, byteOrder binary.ByteOrder, tagType byte) (inte
 			if err != nil {
 				return nil, NbtParseError{"seeking back one", err}
 			}
-			tag, err := Nbt2Json(r, byteOrder)
+			tag, err := Nbt2Json(r, byteOrder, format, mode)
 			if err != nil {
 				return nil, NbtParseError{"compound: reading a child tag", err}
 			}
@@ -190,18 +233,19 @@ func getPayload(r *bytes.Reader, byteOrder binary.ByteOrder, tagType byte) (inte
 		}
 		output = compound
 	case 11:
-		var intArray []int32
-		var oneInt int32
-		numRecords, err := readInt(r, 4, byteOrder)
+		numRecords, err := readCount(r, format, byteOrder)
 		if err != nil {
 			return nil, NbtParseError{"Reading int array tag length", err}
 		}
-		for i := int64(1); i <= numRecords; i++ {
-			err := binary.Read(r, byteOrder, &oneInt)
+		if err = checkCount(r, numRecords, 4); err != nil {
+			return nil, NbtParseError{"Reading int array tag length", err}
+		}
+		intArray := make([]int32, numRecords)
+		if numRecords > 0 {
+			err = binary.Read(r, byteOrder, &intArray)
 			if err != nil {
-				return nil, NbtParseError{"Reading int in int array tag", err}
+				return nil, NbtParseError{"Reading int array tag data", err}
 			}
-			intArray = append(intArray, oneInt)
 		}
 		output = intArray
 	default:
@@ -0,0 +1,150 @@
+package nbt2json
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestCheckCount(t *testing.T) {
+	cases := []struct {
+		name        string
+		count       int64
+		elementSize int64
+		readerLen   int
+		wantErr     bool
+	}{
+		{"negative count", -1, 1, 10, true},
+		{"zero count on empty reader", 0, 1, 0, false},
+		{"count fits", 4, 1, 4, false},
+		{"count exceeds available data", 5, 1, 4, true},
+		{"large element size exceeds available data", 2, 4, 4, true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := bytes.NewReader(make([]byte, c.readerLen))
+			err := checkCount(r, c.count, c.elementSize)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("checkCount(count=%v, elementSize=%v, readerLen=%v) error = %v, wantErr %v",
+					c.count, c.elementSize, c.readerLen, err, c.wantErr)
+			}
+		})
+	}
+}
+
+// TestGetPayloadByteArrayTruncated regression-tests the length-bound check
+// for TAG_Byte_Array: a declared length larger than the remaining data
+// must be rejected with an error, not allocated.
+func TestGetPayloadByteArrayTruncated(t *testing.T) {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, int32(1<<30)) // declared length, no data follows
+	r := bytes.NewReader(buf.Bytes())
+	_, err := getPayload(r, binary.BigEndian, Java, Pretty, 7)
+	if err == nil {
+		t.Fatal("expected error for byte array length exceeding available data, got nil")
+	}
+}
+
+// TestGetPayloadIntArrayTruncated is the TAG_Int_Array counterpart of
+// TestGetPayloadByteArrayTruncated.
+func TestGetPayloadIntArrayTruncated(t *testing.T) {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, int32(1<<30))
+	r := bytes.NewReader(buf.Bytes())
+	_, err := getPayload(r, binary.BigEndian, Java, Pretty, 11)
+	if err == nil {
+		t.Fatal("expected error for int array length exceeding available data, got nil")
+	}
+}
+
+// encodeByteArrayList builds a minimal unnamed TAG_List of TAG_Byte_Array,
+// containing the given byte slices, for use as Nbt2Json/Json2Nbt input.
+func encodeByteArrayList(arrays [][]byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteByte(9) // TAG_List
+	binary.Write(&buf, binary.BigEndian, int16(0))
+	buf.WriteByte(7) // list item type: TAG_Byte_Array
+	binary.Write(&buf, binary.BigEndian, int32(len(arrays)))
+	for _, a := range arrays {
+		binary.Write(&buf, binary.BigEndian, int32(len(a)))
+		buf.Write(a)
+	}
+	return buf.Bytes()
+}
+
+// TestNbt2JsonListOfByteArrayLossless is a regression test for a panic
+// where a TAG_List of TAG_Byte_Array, decoded under mode Lossless, paniced
+// in formatListItem instead of returning a result (it re-applied
+// formatByteArray to a payload that had already been formatted).
+func TestNbt2JsonListOfByteArrayLossless(t *testing.T) {
+	data := encodeByteArrayList([][]byte{{1, 2, 3}, {9, 8}})
+	r := bytes.NewReader(data)
+	out, err := Nbt2Json(r, binary.BigEndian, Java, Lossless)
+	if err != nil {
+		t.Fatalf("Nbt2Json returned error: %v", err)
+	}
+	if len(out) == 0 {
+		t.Fatal("Nbt2Json returned empty output")
+	}
+}
+
+// TestJson2NbtLosslessRoundTrip checks that JSON produced by Nbt2Json in
+// Lossless mode, including a list of byte arrays, re-encodes back to the
+// original NBT bytes via Json2Nbt.
+func TestJson2NbtLosslessRoundTrip(t *testing.T) {
+	original := encodeByteArrayList([][]byte{{1, 2, 3}, {9, 8}})
+	j, err := Nbt2Json(bytes.NewReader(original), binary.BigEndian, Java, Lossless)
+	if err != nil {
+		t.Fatalf("Nbt2Json returned error: %v", err)
+	}
+	roundTripped, err := Json2Nbt(j, binary.BigEndian, Java, Lossless)
+	if err != nil {
+		t.Fatalf("Json2Nbt returned error: %v", err)
+	}
+	if !bytes.Equal(roundTripped, original) {
+		t.Fatalf("round trip mismatch:\n original: %v\nroundTrip: %v", original, roundTripped)
+	}
+}
+
+// bedrockHugeNameLenInput builds a BedrockNetwork compound containing one
+// child tag whose name-length varint declares 1<<40 bytes, with no data
+// following it.
+func bedrockHugeNameLenInput() []byte {
+	var buf bytes.Buffer
+	buf.WriteByte(10) // compound, empty name
+	nameLen := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(nameLen, 0)
+	buf.Write(nameLen[:n])
+	buf.WriteByte(1) // child: TAG_Byte
+	hugeLen := make([]byte, binary.MaxVarintLen64)
+	n = binary.PutUvarint(hugeLen, 1<<40)
+	buf.Write(hugeLen[:n])
+	return buf.Bytes()
+}
+
+// TestNbt2JsonNameLengthTruncated regression-tests a crash where a
+// BedrockNetwork tag/compound-member name length, read as an unbounded
+// varint via readNameLen, went straight into make([]byte, nameLen)
+// without a checkCount guard - a length of 1<<40 with no data behind it
+// killed the process with an out-of-memory fatal error instead of
+// returning an NbtParseError.
+func TestNbt2JsonNameLengthTruncated(t *testing.T) {
+	r := bytes.NewReader(bedrockHugeNameLenInput())
+	_, err := Nbt2Json(r, binary.LittleEndian, BedrockNetwork, Pretty)
+	if err == nil {
+		t.Fatal("expected error for name length exceeding available data, got nil")
+	}
+}
+
+// TestGetPayloadStringLengthTruncated is the TAG_String counterpart of
+// TestNbt2JsonNameLengthTruncated: a string length exceeding available
+// data must be rejected, not allocated.
+func TestGetPayloadStringLengthTruncated(t *testing.T) {
+	hugeLen := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(hugeLen, 1<<40)
+	r := bytes.NewReader(hugeLen[:n])
+	_, err := getPayload(r, binary.LittleEndian, BedrockNetwork, Pretty, 8)
+	if err == nil {
+		t.Fatal("expected error for string length exceeding available data, got nil")
+	}
+}
@@ -0,0 +1,117 @@
+package nbt2json
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// sectorSize is the size in bytes of one sector in a region file, used
+// both for the chunk location table and for chunk data offsets.
+const sectorSize = 4096
+
+// RegionReader reads Minecraft .mca/.mcr region files, which pack up to
+// 32x32 chunks of NBT data into sectors of a single file.
+type RegionReader struct {
+	r         io.ReaderAt
+	byteOrder binary.ByteOrder
+	format    NbtFormat
+	mode      JsonOutputMode
+}
+
+// NewRegionReader wraps r, which must provide random access to a whole
+// .mca/.mcr region file, for reading individual chunks out of it.
+func NewRegionReader(r io.ReaderAt, byteOrder binary.ByteOrder, format NbtFormat, mode JsonOutputMode) *RegionReader {
+	return &RegionReader{r: r, byteOrder: byteOrder, format: format, mode: mode}
+}
+
+// ReadChunk returns the NBT data for the chunk at (cx, cz), given as
+// chunk coordinates within the region (0-31), converted to JSON via
+// Nbt2Json. It returns NbtParseError if the chunk is not present.
+func (rr *RegionReader) ReadChunk(cx, cz int) ([]byte, error) {
+	cx = ((cx % 32) + 32) % 32
+	cz = ((cz % 32) + 32) % 32
+	entry := make([]byte, 4)
+	_, err := rr.r.ReadAt(entry, int64((cx+cz*32)*4))
+	if err != nil {
+		return nil, NbtParseError{"Reading chunk location table entry", err}
+	}
+	sectorOffset := int64(entry[0])<<16 | int64(entry[1])<<8 | int64(entry[2])
+	sectorCount := entry[3]
+	if sectorOffset == 0 && sectorCount == 0 {
+		return nil, NbtParseError{fmt.Sprintf("Chunk %v,%v is not present in region file", cx, cz), nil}
+	}
+
+	lengthAndType := make([]byte, 5)
+	_, err = rr.r.ReadAt(lengthAndType, sectorOffset*sectorSize)
+	if err != nil {
+		return nil, NbtParseError{"Reading chunk data header", err}
+	}
+	length := int64(binary.BigEndian.Uint32(lengthAndType[0:4]))
+	compressionType := lengthAndType[4]
+
+	// length includes the compression type byte already read above, and
+	// the whole record (the length field plus length bytes) must fit in
+	// the sectors this chunk's location table entry reserved for it.
+	maxLength := int64(sectorCount)*sectorSize - 4
+	if length < 1 || length > maxLength {
+		return nil, NbtParseError{fmt.Sprintf("Chunk %v,%v has invalid data length %v", cx, cz, length), ErrTruncated}
+	}
+
+	compressed := make([]byte, length-1)
+	_, err = rr.r.ReadAt(compressed, sectorOffset*sectorSize+5)
+	if err != nil {
+		return nil, NbtParseError{"Reading chunk data", err}
+	}
+
+	decompressed, err := decompressChunk(compressed, compressionType)
+	if err != nil {
+		return nil, err
+	}
+	return Nbt2Json(bytes.NewReader(decompressed), rr.byteOrder, rr.format, rr.mode)
+}
+
+// decompressChunk decompresses a chunk record's payload according to the
+// compression byte that precedes it in the region file: 1 is gzip, 2 is
+// zlib, and 3 is uncompressed.
+func decompressChunk(compressed []byte, compressionType byte) ([]byte, error) {
+	switch compressionType {
+	case 1:
+		gzReader, err := gzip.NewReader(bytes.NewReader(compressed))
+		if err != nil {
+			return nil, NbtParseError{"Opening gzip chunk data", err}
+		}
+		defer gzReader.Close()
+		return io.ReadAll(gzReader)
+	case 2:
+		zlibReader, err := zlib.NewReader(bytes.NewReader(compressed))
+		if err != nil {
+			return nil, NbtParseError{"Opening zlib chunk data", err}
+		}
+		defer zlibReader.Close()
+		return io.ReadAll(zlibReader)
+	case 3:
+		return compressed, nil
+	default:
+		return nil, NbtParseError{fmt.Sprintf("Unrecognized chunk compression type %v", compressionType), nil}
+	}
+}
+
+// ReadLevelDat reads a gzip-compressed level.dat (as found at the top
+// level of a Minecraft world save) and returns its NBT data as JSON via
+// Nbt2Json.
+func ReadLevelDat(r io.Reader, byteOrder binary.ByteOrder, format NbtFormat, mode JsonOutputMode) ([]byte, error) {
+	gzReader, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, NbtParseError{"Opening gzip level.dat", err}
+	}
+	defer gzReader.Close()
+	decompressed, err := io.ReadAll(gzReader)
+	if err != nil {
+		return nil, NbtParseError{"Decompressing level.dat", err}
+	}
+	return Nbt2Json(bytes.NewReader(decompressed), byteOrder, format, mode)
+}
@@ -0,0 +1,48 @@
+package nbt2json
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// newTestRegion builds a minimal in-memory region file with a single
+// chunk at (0, 0), whose data sector starts right after the 8KiB location
+// and timestamp tables, with the given declared data length and
+// compression type.
+func newTestRegion(length int32, compressionType byte) []byte {
+	const dataSector = 2 // sector 0 is the location table, sector 1 the timestamp table
+	buf := make([]byte, dataSector*sectorSize+5)
+	// location table entry for chunk (0,0): 3-byte sector offset, 1-byte sector count
+	buf[0] = 0
+	buf[1] = 0
+	buf[2] = dataSector
+	buf[3] = 1
+	binary.BigEndian.PutUint32(buf[dataSector*sectorSize:], uint32(length))
+	buf[dataSector*sectorSize+4] = compressionType
+	return buf
+}
+
+// TestReadChunkZeroLength regression-tests a panic where a chunk data
+// header declaring length 0 made ReadChunk compute make([]byte, -1),
+// which panics with "makeslice: len out of range" instead of returning
+// an error.
+func TestReadChunkZeroLength(t *testing.T) {
+	region := newTestRegion(0, 3)
+	rr := NewRegionReader(bytes.NewReader(region), binary.BigEndian, Java, Pretty)
+	_, err := rr.ReadChunk(0, 0)
+	if err == nil {
+		t.Fatal("expected error for zero-length chunk data, got nil")
+	}
+}
+
+// TestReadChunkLengthExceedsSectors checks that a declared length larger
+// than the sectors reserved for the chunk is rejected before allocating.
+func TestReadChunkLengthExceedsSectors(t *testing.T) {
+	region := newTestRegion(1<<30, 3)
+	rr := NewRegionReader(bytes.NewReader(region), binary.BigEndian, Java, Pretty)
+	_, err := rr.ReadChunk(0, 0)
+	if err == nil {
+		t.Fatal("expected error for chunk data length exceeding its reserved sectors, got nil")
+	}
+}